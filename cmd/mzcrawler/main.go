@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 
 	"github.com/guilherme-santos/mzcrawler/http"
+	"github.com/guilherme-santos/mzcrawler/output"
 )
 
 var defaultConcurrenctClients uint = 5
@@ -15,6 +17,9 @@ var (
 	followSubDomains = flag.Bool("subdomains", false, "sets if should follow subdomains")
 	verbose          = flag.Bool("v", false, "log the crawler progress")
 	concurrent       = flag.Uint("n", defaultConcurrenctClients, "number of concurrent http calls")
+	maxDepth         = flag.Int("max-depth", 0, "maximum number of links to follow from the starting URL, 0 means no limit")
+	maxPages         = flag.Int("max-pages", 0, "maximum number of pages to crawl, 0 means no limit")
+	outputFormat     = flag.String("output", "json", "output format of the sitemap: xml, dot, json or ndjson")
 )
 
 func main() {
@@ -32,6 +37,21 @@ func main() {
 
 	baseurl := flag.Arg(0)
 
+	// ndjson streams records as pages are crawled, so it doesn't go
+	// through output.Writer like the other formats do.
+	streaming := *outputFormat == "ndjson"
+
+	var w output.Writer
+	if !streaming {
+		var err error
+		w, err = outputWriter(*outputFormat)
+		if err != nil {
+			fmt.Println(err)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
 	c, err := http.NewWebCrawler(baseurl, *concurrent)
 	if err != nil {
 		fmt.Printf("Unable to create a crawler to %s: %s\n", baseurl, err)
@@ -39,14 +59,45 @@ func main() {
 	}
 	c.Verbose = *verbose
 	c.FollowSubDomains = *followSubDomains
+	c.MaxDepth = *maxDepth
+	c.MaxPages = *maxPages
 
-	sitemap, err := c.Crawl()
+	if streaming {
+		enc := json.NewEncoder(os.Stdout)
+		c.OnPageCrawled = func(url string, links []string) {
+			enc.Encode(output.PageRecord{URL: url, Links: links})
+		}
+	}
+
+	sitemap, err := c.Crawl(context.Background())
 	if err != nil {
 		fmt.Printf("Unable to crawl %s: %s\n", baseurl, err)
 		os.Exit(1)
 	}
 
-	// Print sitemap returned.
-	j, _ := json.MarshalIndent(sitemap, "", "   ")
-	fmt.Println(string(j))
+	if streaming {
+		return
+	}
+
+	if err := w.Write(sitemap, os.Stdout); err != nil {
+		fmt.Printf("Unable to write sitemap: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// outputWriter returns the output.Writer matching format, or an error
+// if format isn't one of the supported output formats.
+func outputWriter(format string) (output.Writer, error) {
+	switch format {
+	case "xml":
+		return output.NewXMLWriter(), nil
+	case "dot":
+		return output.NewDOTWriter(), nil
+	case "json":
+		return output.NewJSONWriter(), nil
+	case "ndjson":
+		return output.NewNDJSONWriter(), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
 }
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// hostLimiter is a simple token-bucket rate limiter used to cap the
+// number of requests per second sent to a single host.
+type hostLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	rate   float64 // tokens added per second
+	last   time.Time
+}
+
+// newHostLimiter creates a limiter allowing requestsPerSecond requests
+// per second, or an unlimited one when requestsPerSecond is zero.
+func newHostLimiter(requestsPerSecond float64) *hostLimiter {
+	rate := requestsPerSecond
+	if rate <= 0 {
+		rate = math.Inf(1)
+	}
+
+	return &hostLimiter{
+		tokens: rate,
+		rate:   rate,
+		last:   time.Now(),
+	}
+}
+
+// setRate lowers the limiter's rate when r is slower than what's
+// currently configured, used to honour a host's robots.txt Crawl-delay.
+func (l *hostLimiter) setRate(r float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r < l.rate {
+		l.rate = r
+	}
+}
+
+// wait blocks until a token is available, i.e. until it's safe to send
+// another request to the host this limiter belongs to.
+func (l *hostLimiter) wait() {
+	for {
+		l.mu.Lock()
+
+		if math.IsInf(l.rate, 1) {
+			l.mu.Unlock()
+			return
+		}
+
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.rate {
+			l.tokens = l.rate
+		}
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return
+		}
+
+		sleep := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		time.Sleep(sleep)
+	}
+}
+
+// hostLimiterFor returns the rate limiter for host, creating one the
+// first time it's requested.
+func (c *WebCrawler) hostLimiterFor(host string) *hostLimiter {
+	c.rateLimitersMu.Lock()
+	defer c.rateLimitersMu.Unlock()
+
+	l, ok := c.rateLimiters[host]
+	if !ok {
+		l = newHostLimiter(c.RequestsPerSecond)
+		c.rateLimiters[host] = l
+	}
+	return l
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter_Wait(t *testing.T) {
+	l := newHostLimiter(10) // 10 req/s, i.e. one token every 100ms.
+	l.tokens = 0            // force the next wait() to actually block.
+
+	start := time.Now()
+	l.wait()
+	elapsed := time.Since(start)
+
+	if elapsed < 80*time.Millisecond {
+		t.Errorf("expected wait() to block for about 100ms, only waited %s", elapsed)
+	}
+}
+
+func TestHostLimiter_Unlimited(t *testing.T) {
+	l := newHostLimiter(0)
+
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		l.wait()
+	}
+	elapsed := time.Since(start)
+
+	if elapsed > 50*time.Millisecond {
+		t.Errorf("expected an unlimited limiter to never block, 1000 waits took %s", elapsed)
+	}
+}
+
+func TestHostLimiter_SetRate(t *testing.T) {
+	l := newHostLimiter(100)
+	l.setRate(10) // Crawl-delay slower than the configured rate wins.
+	if l.rate != 10 {
+		t.Errorf("expected rate to drop to 10, got %v", l.rate)
+	}
+
+	l.setRate(50) // a faster rate should never be allowed to win.
+	if l.rate != 10 {
+		t.Errorf("expected rate to stay at 10, got %v", l.rate)
+	}
+}
+
+func TestCrawler_RequestsPerSecond(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		timestamps []time.Time
+	)
+
+	// newHostLimiter starts with a full bucket, so the rate only becomes
+	// visible once the burst (rate tokens) is used up. Chain enough
+	// pages, one link each, to run well past that burst.
+	const (
+		requestsPerSecond = 20
+		chainLen          = requestsPerSecond * 2
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mu.Lock()
+		timestamps = append(timestamps, time.Now())
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+
+		var n int
+		fmt.Sscanf(req.URL.Path, "/page%d", &n)
+		if n < chainLen {
+			fmt.Fprintf(w, `<a href="/page%d">`, n+1)
+		}
+	}))
+	defer ts.Close()
+
+	// concurrency 1 so the limiter (shared per host) is the only thing
+	// pacing requests, not contention for worker slots.
+	c, err := NewWebCrawler(ts.URL, 1)
+	noError(t, err)
+	c.RequestsPerSecond = requestsPerSecond // one request every 50ms
+
+	_, err = c.Crawl(context.Background())
+	noError(t, err)
+
+	if len(timestamps) <= requestsPerSecond {
+		t.Fatalf("expected more than %d requests to run past the initial burst, got %d", requestsPerSecond, len(timestamps))
+	}
+
+	// Only the requests past the initial burst are expected to be
+	// spaced out; skip an extra one since the burst can spill into it.
+	for i := requestsPerSecond + 2; i < len(timestamps); i++ {
+		gap := timestamps[i].Sub(timestamps[i-1])
+		if gap < 40*time.Millisecond {
+			t.Errorf("requests %d and %d were only %s apart, expected at least ~50ms", i-1, i, gap)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules holds the directives that apply to this crawler for a
+// single host, as parsed from that host's robots.txt.
+type robotsRules struct {
+	disallow   []string
+	allow      []string
+	crawlDelay time.Duration
+}
+
+// allowAllRobots is returned whenever robots.txt can't be fetched or
+// parsed, since the absence of a robots.txt means everything is allowed.
+var allowAllRobots = &robotsRules{}
+
+// allows reports whether path is allowed to be crawled according to r.
+// The longest matching rule wins, as specified by the robots.txt de
+// facto standard; ties are broken in favour of Allow.
+func (r *robotsRules) allows(path string) bool {
+	var (
+		matched   string
+		isAllowed = true
+	)
+
+	for _, rule := range r.disallow {
+		if rule == "" {
+			// An empty Disallow means "allow everything".
+			continue
+		}
+		if strings.HasPrefix(path, rule) && len(rule) >= len(matched) {
+			matched = rule
+			isAllowed = false
+		}
+	}
+
+	for _, rule := range r.allow {
+		if strings.HasPrefix(path, rule) && len(rule) >= len(matched) {
+			matched = rule
+			isAllowed = true
+		}
+	}
+
+	return isAllowed
+}
+
+// parseRobotsTxt parses the content of a robots.txt file and returns
+// the rules that apply to userAgent, falling back to the rules of the
+// "*" group when there's no group specific to it.
+func parseRobotsTxt(r io.Reader, userAgent string) *robotsRules {
+	var (
+		groups    = make(map[string]*robotsRules)
+		curAgents []string
+		lastField string
+	)
+
+	group := func(agent string) *robotsRules {
+		if _, ok := groups[agent]; !ok {
+			groups[agent] = &robotsRules{}
+		}
+		return groups[agent]
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		idx := strings.IndexByte(line, ':')
+		if idx < 0 {
+			continue
+		}
+
+		field := strings.ToLower(strings.TrimSpace(line[:idx]))
+		value := strings.TrimSpace(line[idx+1:])
+
+		switch field {
+		case "user-agent":
+			if lastField != "user-agent" {
+				curAgents = nil
+			}
+			curAgents = append(curAgents, strings.ToLower(value))
+		case "disallow":
+			for _, agent := range curAgents {
+				g := group(agent)
+				g.disallow = append(g.disallow, value)
+			}
+		case "allow":
+			for _, agent := range curAgents {
+				g := group(agent)
+				g.allow = append(g.allow, value)
+			}
+		case "crawl-delay":
+			if secs, err := strconv.ParseFloat(value, 64); err == nil {
+				for _, agent := range curAgents {
+					g := group(agent)
+					g.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+
+		lastField = field
+	}
+
+	userAgent = strings.ToLower(userAgent)
+	for agent, rules := range groups {
+		if agent != "*" && strings.Contains(userAgent, agent) {
+			return rules
+		}
+	}
+	if rules, ok := groups["*"]; ok {
+		return rules
+	}
+	return allowAllRobots
+}
+
+// robotsRulesFor returns the robots.txt rules for u's host, fetching
+// and caching them on the first call for that host.
+func (c *WebCrawler) robotsRulesFor(u *url.URL) *robotsRules {
+	c.robotsMu.Lock()
+	if rules, ok := c.robotsCache[u.Host]; ok {
+		c.robotsMu.Unlock()
+		return rules
+	}
+	c.robotsMu.Unlock()
+
+	rules := c.fetchRobotsTxt(u)
+
+	c.robotsMu.Lock()
+	c.robotsCache[u.Host] = rules
+	c.robotsMu.Unlock()
+
+	return rules
+}
+
+func (c *WebCrawler) fetchRobotsTxt(u *url.URL) *robotsRules {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+
+	req, err := http.NewRequest(http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		c.log("unable to fetch robots.txt, allowing everything", logRecord{"url": robotsURL.String()})
+		return allowAllRobots
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		c.log("unable to fetch robots.txt, allowing everything", logRecord{"url": robotsURL.String()})
+		return allowAllRobots
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return allowAllRobots
+	}
+
+	return parseRobotsTxt(resp.Body, c.UserAgent)
+}
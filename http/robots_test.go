@@ -0,0 +1,52 @@
+package http
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobotsTxt(t *testing.T) {
+	body := `
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Crawl-delay: 2
+
+User-agent: mzcrawler
+Disallow: /no-bots
+`
+
+	rules := parseRobotsTxt(strings.NewReader(body), "mzcrawler")
+	assert(t, []string{"/no-bots"}, rules.disallow)
+
+	rules = parseRobotsTxt(strings.NewReader(body), "other-bot")
+	assert(t, []string{"/private"}, rules.disallow)
+	assert(t, []string{"/private/public"}, rules.allow)
+	assert(t, 2*time.Second, rules.crawlDelay)
+}
+
+func TestRobotsRulesAllows(t *testing.T) {
+	rules := &robotsRules{
+		disallow: []string{"/private"},
+		allow:    []string{"/private/public"},
+	}
+
+	testcases := []struct {
+		Path    string
+		Allowed bool
+	}{
+		{Path: "/", Allowed: true},
+		{Path: "/blog", Allowed: true},
+		{Path: "/private", Allowed: false},
+		{Path: "/private/page", Allowed: false},
+		{Path: "/private/public", Allowed: true},
+		{Path: "/private/public/page", Allowed: true},
+	}
+
+	for _, tc := range testcases {
+		if !assert(t, tc.Allowed, rules.allows(tc.Path)) {
+			t.Logf("path %s", tc.Path)
+		}
+	}
+}
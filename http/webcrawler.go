@@ -2,18 +2,60 @@ package http
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/guilherme-santos/mzcrawler"
 	"golang.org/x/net/html"
+	"golang.org/x/sync/errgroup"
 )
 
+// cssURLRegexp matches url(...) references inside CSS, such as
+// `background: url("/bg.png")` or `@import url(theme.css)`. It's matched
+// with FindAllStringSubmatch so it deliberately has no prefix requiring
+// a preceding "@import" or ":" - that would make the match greedy over
+// the rest of the line and swallow every url() but the last one when
+// more than one appears together.
+var cssURLRegexp = regexp.MustCompile(`url\(["']?([^'"\)]+)["']?\)`)
+
+// urlKind tags a URL found while crawling a page as either followable
+// or just a reference to an asset.
+type urlKind int
+
+const (
+	// primaryURL is a link the crawler can recurse into, e.g. <a href>.
+	primaryURL urlKind = iota
+	// relatedURL is an asset referenced by the page (image, script,
+	// stylesheet, ...) that's recorded but never fetched.
+	relatedURL
+)
+
+// foundURL is a URL discovered while parsing a page, tagged with its
+// kind so the caller knows whether to follow it.
+type foundURL struct {
+	URL  string
+	Kind urlKind
+}
+
+// defaultAllowedContentTypes is used when AllowedContentTypes is empty.
+var defaultAllowedContentTypes = []string{"text/html"}
+
+// queueItem is a URL waiting to be crawled by the worker pool, along
+// with how many links away from the starting URL it is.
+type queueItem struct {
+	url   string
+	depth int
+}
+
 // ClientTimeout defines the timeout when do http calls.
 var ClientTimeout = 5 * time.Second
 
@@ -24,13 +66,55 @@ type WebCrawler struct {
 	domain           string
 	sitemap          mzcrawler.Sitemap
 	sitemapMu        sync.Mutex
-	semaphore        chan struct{}
+	concurrency      uint
+	queue            chan queueItem
+	discovered       chan queueItem
+	cancel           context.CancelFunc
+	errMu            sync.Mutex
+	err              error
+	robotsCache      map[string]*robotsRules
+	robotsMu         sync.Mutex
+	rateLimiters     map[string]*hostLimiter
+	rateLimitersMu   sync.Mutex
 	Logger           *log.Logger
 	HTTPClient       *http.Client
 	Verbose          bool
 	FollowSubDomains bool
+	// UserAgent is sent as the User-Agent header on every request, and
+	// is also used to identify which robots.txt group applies to this
+	// crawler; it defaults to "mzcrawler".
+	UserAgent string
+	// RequestsPerSecond caps how many requests are sent to any single
+	// host per second. Zero means no limit.
+	RequestsPerSecond float64
+	// MaxDepth limits how many links away from the starting URL the
+	// crawler will follow. Zero means no limit.
+	MaxDepth int
+	// MaxPages limits how many distinct URLs the crawler will visit.
+	// Zero means no limit.
+	MaxPages int
+	// StopOnError aborts the whole crawl as soon as any single fetch
+	// or parse fails. When false, the failing URL is skipped and the
+	// first error encountered is still returned once the crawl finishes.
+	StopOnError bool
+	// OnPageCrawled, when set, is called every time a page finishes
+	// crawling and its entry is stored in the sitemap, so callers can
+	// stream results instead of waiting for Crawl to return.
+	OnPageCrawled func(url string, links []string)
+	// MaxResponseBytes caps how many bytes of a response body are read
+	// before it's parsed. Zero means defaultMaxResponseBytes.
+	MaxResponseBytes int64
+	// AllowedContentTypes lists the Content-Type values (ignoring any
+	// charset parameter) the crawler will parse for links. A response
+	// whose Content-Type isn't in the list is recorded in the sitemap
+	// as a leaf, with no links or assets, but never parsed. Empty means
+	// []string{"text/html"}.
+	AllowedContentTypes []string
 }
 
+// defaultMaxResponseBytes is used when MaxResponseBytes is zero.
+const defaultMaxResponseBytes = 1 << 20 // 1 MiB
+
 // NewWebCrawler creates new instance of http.WebCrawler.
 func NewWebCrawler(baseurl string, concurrent uint) (*WebCrawler, error) {
 	u, err := url.Parse(baseurl)
@@ -39,17 +123,22 @@ func NewWebCrawler(baseurl string, concurrent uint) (*WebCrawler, error) {
 	}
 
 	return &WebCrawler{
-		urlstr:    baseurl,
-		url:       u,
-		domain:    domain(u),
-		sitemap:   make(mzcrawler.Sitemap),
-		semaphore: make(chan struct{}, concurrent),
-		Logger:    log.New(os.Stdout, "http.webcrawler: ", 0),
+		urlstr:       baseurl,
+		url:          u,
+		domain:       domain(u),
+		sitemap:      make(mzcrawler.Sitemap),
+		concurrency:  concurrent,
+		queue:        make(chan queueItem, concurrent),
+		discovered:   make(chan queueItem),
+		robotsCache:  make(map[string]*robotsRules),
+		rateLimiters: make(map[string]*hostLimiter),
+		Logger:       log.New(os.Stdout, "http.webcrawler: ", 0),
 		HTTPClient: &http.Client{
 			Timeout: ClientTimeout,
 		},
 		Verbose:          false,
 		FollowSubDomains: true,
+		UserAgent:        "mzcrawler",
 	}, nil
 }
 
@@ -77,21 +166,173 @@ func (c *WebCrawler) log(msg string, data logRecord) {
 	}
 }
 
-func (c *WebCrawler) Crawl() (mzcrawler.Sitemap, error) {
-	var wg sync.WaitGroup
+func (c *WebCrawler) Crawl(ctx context.Context) (mzcrawler.Sitemap, error) {
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.cancel = cancel
+	c.err = nil
 
-	err := c.worker(&wg, c.urlstr)
-	if err != nil {
-		return nil, err
+	// pending tracks URLs that have been queued but not yet processed,
+	// so we know when there's no more work left and can stop the pool.
+	var pending sync.WaitGroup
+
+	g, gctx := errgroup.WithContext(cctx)
+
+	// feedQueue buffers links discovered by workers in memory and
+	// forwards them to c.queue as workers free up, so a worker handing
+	// off the links it just found never blocks on queue space itself.
+	go c.feedQueue(gctx, &pending)
+
+	for i := uint(0); i < c.concurrency; i++ {
+		g.Go(func() error {
+			return c.runWorker(gctx, &pending)
+		})
+	}
+
+	pending.Add(1)
+	select {
+	case c.discovered <- queueItem{url: c.urlstr, depth: 0}:
+	case <-cctx.Done():
+		pending.Done()
 	}
 
-	// Wait read from all crawled URL to return.
-	wg.Wait()
+	go func() {
+		// Once every queued URL has been processed there's no more
+		// work for the pool, so cancel to let the workers return.
+		pending.Wait()
+		cancel()
+	}()
+
+	errgroupErr := g.Wait()
+
+	// Every worker has returned, so nothing else will ever receive
+	// from c.queue. Drain whatever's left in it and mark those items
+	// done, otherwise their pending.Add(1) is never matched and the
+	// goroutine above is stranded in pending.Wait() forever.
+	c.drainQueue(&pending)
+
+	if errgroupErr != nil {
+		return nil, errgroupErr
+	}
+
+	if c.err != nil {
+		return c.sitemap, c.err
+	}
+
+	// Only report a context error when the caller's own ctx triggered
+	// the stop; hitting MaxPages is a deliberate, successful stop.
+	if err := ctx.Err(); err != nil {
+		return c.sitemap, err
+	}
 	return c.sitemap, nil
 }
 
+// feedQueue buffers items pushed onto c.discovered in an in-memory
+// slice and forwards them to c.queue one at a time as workers free up.
+// It's the only goroutine that ever blocks trying to send to c.queue,
+// so a worker handing off a link it just found from inside process
+// never blocks holding its own queue slot, no matter how many links a
+// single page turns up.
+func (c *WebCrawler) feedQueue(ctx context.Context, pending *sync.WaitGroup) {
+	var buf []queueItem
+
+	for {
+		if len(buf) == 0 {
+			select {
+			case item := <-c.discovered:
+				buf = append(buf, item)
+			case <-ctx.Done():
+				c.drainDiscovered(pending, buf)
+				return
+			}
+			continue
+		}
+
+		select {
+		case item := <-c.discovered:
+			buf = append(buf, item)
+		case c.queue <- buf[0]:
+			buf = buf[1:]
+		case <-ctx.Done():
+			c.drainDiscovered(pending, buf)
+			return
+		}
+	}
+}
+
+// drainDiscovered marks buf, plus anything still waiting on
+// c.discovered, as done. It's called when feedQueue gives up on a
+// cancelled crawl while still holding links nobody will ever forward
+// to c.queue - without this, their earlier pending.Add(1) is never
+// matched and Crawl's pending.Wait() blocks forever.
+func (c *WebCrawler) drainDiscovered(pending *sync.WaitGroup, buf []queueItem) {
+	for range buf {
+		pending.Done()
+	}
+	for {
+		select {
+		case <-c.discovered:
+			pending.Done()
+		default:
+			return
+		}
+	}
+}
+
+// drainQueue marks every item still sitting in c.queue as done. It's
+// called after every worker has returned, since at that point nothing
+// else will ever receive from c.queue to do it for them.
+func (c *WebCrawler) drainQueue(pending *sync.WaitGroup) {
+	for {
+		select {
+		case <-c.queue:
+			pending.Done()
+		default:
+			return
+		}
+	}
+}
+
+// runWorker pulls URLs off the queue until ctx is cancelled, either
+// because the crawl finished or because StopOnError aborted it.
+func (c *WebCrawler) runWorker(ctx context.Context, pending *sync.WaitGroup) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case item := <-c.queue:
+			if err := c.process(ctx, pending, item); err != nil {
+				if ctx.Err() != nil {
+					// ctx was cancelled (crawl finished, MaxPages was
+					// hit, or the caller cancelled it); err is just a
+					// symptom of that shutdown, not a real failure.
+					return nil
+				}
+
+				c.recordErr(err)
+				if c.StopOnError {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// recordErr keeps the first error seen by any worker, so Crawl can
+// report it once every worker has stopped.
+func (c *WebCrawler) recordErr(err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	if c.err == nil {
+		c.err = err
+	}
+}
+
 // newURLFound checks if URL was visited already, case not it'll
-// add it in the list of visited URLs.
+// add it in the list of visited URLs. The call that brings the sitemap
+// to MaxPages entries cancels the crawl so outstanding workers can exit
+// early, and every later call for a new URL returns false.
 func (c *WebCrawler) newURLFound(urlstr string) bool {
 	c.sitemapMu.Lock()
 	defer c.sitemapMu.Unlock()
@@ -100,30 +341,53 @@ func (c *WebCrawler) newURLFound(urlstr string) bool {
 		return false
 	}
 
-	c.sitemap[urlstr] = make([]string, 0)
+	if c.MaxPages > 0 && len(c.sitemap) >= c.MaxPages {
+		return false
+	}
+
+	c.sitemap[urlstr] = mzcrawler.Page{}
+
+	if c.MaxPages > 0 && len(c.sitemap) >= c.MaxPages && c.cancel != nil {
+		c.cancel()
+	}
+
 	return true
 }
 
-func (c *WebCrawler) worker(wg *sync.WaitGroup, urlstr string) error {
-	if !c.newURLFound(urlstr) {
-		c.log("url visited already, ignoring...", logRecord{"url": urlstr})
+// process crawls a single queued item, enqueuing any primary links it
+// finds for other workers to pick up and saving its Page once done.
+func (c *WebCrawler) process(ctx context.Context, pending *sync.WaitGroup, item queueItem) error {
+	defer pending.Done()
+
+	if c.MaxDepth > 0 && item.depth > c.MaxDepth {
+		c.log("max depth reached, ignoring...", logRecord{"url": item.url})
 		return nil
 	}
 
-	// crawl urlstr
-	urlCh, err := c.crawlURL(urlstr)
+	if !c.newURLFound(item.url) {
+		c.log("url visited already, ignoring...", logRecord{"url": item.url})
+		return nil
+	}
+
+	// crawl item.url
+	urlCh, err := c.crawlURL(ctx, item.url)
 	if err != nil {
 		return err
 	}
 
-	// urls it's a set of URL (avoiding duplicated).
-	urls := make(map[string]struct{})
+	// urls it's a set of URL (avoiding duplicated), keeping the most
+	// "followable" kind seen for each one.
+	urls := make(map[string]urlKind)
+
+	for found := range urlCh {
+		urlstr := c.normalizeURL(found.URL)
 
-	for u := range urlCh {
-		urlstr := c.normalizeURL(u)
+		if kind, ok := urls[urlstr]; !ok || (kind == relatedURL && found.Kind == primaryURL) {
+			urls[urlstr] = found.Kind
+		}
 
-		if _, ok := urls[urlstr]; !ok {
-			urls[urlstr] = struct{}{}
+		if found.Kind != primaryURL {
+			continue
 		}
 
 		if !c.shouldFollow(urlstr) {
@@ -131,44 +395,58 @@ func (c *WebCrawler) worker(wg *sync.WaitGroup, urlstr string) error {
 			continue
 		}
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			c.worker(wg, urlstr)
-		}()
+		pending.Add(1)
+		select {
+		case c.discovered <- queueItem{url: urlstr, depth: item.depth + 1}:
+		case <-ctx.Done():
+			pending.Done()
+		}
 	}
 
-	// create sitemap to urlstr and save it.
-	sitemap := func() (res []string) {
-		for u := range urls {
-			res = append(res, u)
+	// build the page for item.url and save it.
+	var page mzcrawler.Page
+	for u, kind := range urls {
+		if kind == primaryURL {
+			page.Links = append(page.Links, u)
+		} else {
+			page.Assets = append(page.Assets, u)
 		}
-		return
-	}()
+	}
 
 	c.sitemapMu.Lock()
-	c.sitemap[urlstr] = sitemap
+	c.sitemap[item.url] = page
 	c.sitemapMu.Unlock()
 
+	if c.OnPageCrawled != nil {
+		c.OnPageCrawled(item.url, page.Links)
+	}
+
 	return nil
 }
 
 // crawlURL calls baseurl and return an channel that will be send all
 // urls founds in the baseurl.
-func (c *WebCrawler) crawlURL(baseurl string) (chan string, error) {
-	// try to acquire one spot. it'll block until
-	// at least one spot is available.
-	c.semaphore <- struct{}{}
-	defer func() {
-		// When finish the http call release the stop occupied.
-		<-c.semaphore
-	}()
+func (c *WebCrawler) crawlURL(ctx context.Context, baseurl string) (chan foundURL, error) {
+	if u, err := url.Parse(baseurl); err == nil {
+		limiter := c.hostLimiterFor(u.Host)
+		if delay := c.robotsRulesFor(u).crawlDelay; delay > 0 {
+			limiter.setRate(1 / delay.Seconds())
+		}
+		limiter.wait()
+	}
 
 	c.log("crawling...", logRecord{"url": baseurl})
 
-	urlCh := make(chan string)
+	urlCh := make(chan foundURL)
 
-	resp, err := c.HTTPClient.Get(baseurl)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseurl, nil)
+	if err != nil {
+		close(urlCh)
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		close(urlCh)
 		return nil, err
@@ -176,7 +454,32 @@ func (c *WebCrawler) crawlURL(baseurl string) (chan string, error) {
 
 	defer resp.Body.Close()
 
-	doc, err := html.Parse(resp.Body)
+	maxBytes := c.MaxResponseBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxResponseBytes
+	}
+
+	if !c.contentTypeAllowed(resp.Header.Get("Content-Type")) {
+		c.log("content-type not allowed, skipping parse", logRecord{"url": baseurl})
+		// Drain up to maxBytes so the connection can be reused for the
+		// next request to this host instead of being closed, without
+		// downloading an unbounded body just to throw it away.
+		io.CopyN(io.Discard, resp.Body, maxBytes)
+		close(urlCh)
+		return urlCh, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		close(urlCh)
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		c.log("response truncated at MaxResponseBytes", logRecord{"url": baseurl})
+		body = body[:maxBytes]
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
 	if err != nil {
 		close(urlCh)
 		return nil, err
@@ -186,18 +489,39 @@ func (c *WebCrawler) crawlURL(baseurl string) (chan string, error) {
 		// goroutine to handle the html and extract new urls.
 		var fn func(*html.Node)
 		fn = func(n *html.Node) {
-			if n.Type == html.ElementNode && n.Data == "a" {
-				for _, a := range n.Attr {
-					if a.Key == "href" {
-						val := strings.TrimSpace(a.Val)
-						if val == "" || strings.HasPrefix(val, "#") {
-							continue
+			if n.Type == html.ElementNode {
+				switch n.Data {
+				case "a":
+					if val, ok := attr(n, "href"); ok {
+						sendURL(urlCh, val, primaryURL)
+					}
+				case "link":
+					if val, ok := attr(n, "href"); ok {
+						sendURL(urlCh, val, relatedURL)
+					}
+				case "script":
+					if val, ok := attr(n, "src"); ok {
+						sendURL(urlCh, val, relatedURL)
+					}
+				case "img":
+					if val, ok := attr(n, "src"); ok {
+						sendURL(urlCh, val, relatedURL)
+					}
+				case "iframe":
+					if val, ok := attr(n, "src"); ok {
+						sendURL(urlCh, val, relatedURL)
+					}
+				case "style":
+					for c := n.FirstChild; c != nil; c = c.NextSibling {
+						if c.Type == html.TextNode {
+							sendCSSURLs(urlCh, c.Data)
 						}
-
-						urlCh <- val
-						break
 					}
 				}
+
+				if val, ok := attr(n, "style"); ok {
+					sendCSSURLs(urlCh, val)
+				}
 			}
 
 			for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -212,13 +536,66 @@ func (c *WebCrawler) crawlURL(baseurl string) (chan string, error) {
 	return urlCh, nil
 }
 
+// attr returns the value of the attribute key in n, trimmed of
+// surrounding whitespace.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val), true
+		}
+	}
+	return "", false
+}
+
+// sendURL sends val on ch tagged as kind, skipping empty values and
+// in-page fragments.
+func sendURL(ch chan foundURL, val string, kind urlKind) {
+	if val == "" || strings.HasPrefix(val, "#") {
+		return
+	}
+	ch <- foundURL{URL: val, Kind: kind}
+}
+
+// sendCSSURLs extracts every url(...) reference from css and sends
+// each one on ch tagged as a related (asset) URL.
+func sendCSSURLs(ch chan foundURL, css string) {
+	for _, m := range cssURLRegexp.FindAllStringSubmatch(css, -1) {
+		sendURL(ch, strings.TrimSpace(m[1]), relatedURL)
+	}
+}
+
+// contentTypeAllowed reports whether contentType (as sent in a
+// response's Content-Type header, with an optional charset parameter)
+// is one the crawler should parse for links.
+func (c *WebCrawler) contentTypeAllowed(contentType string) bool {
+	allowed := c.AllowedContentTypes
+	if len(allowed) == 0 {
+		allowed = defaultAllowedContentTypes
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+
+	for _, a := range allowed {
+		if strings.EqualFold(mediaType, a) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *WebCrawler) shouldFollow(baseurl string) bool {
 	u, err := url.Parse(baseurl)
 	if err != nil {
 		return false
 	}
 
-	// TODO it'll be nice check robots.txt to avoid crawl URL that shouldn't.
+	if !c.robotsRulesFor(u).allows(u.Path) {
+		c.log("url disallowed by robots.txt", logRecord{"url": baseurl})
+		return false
+	}
 
 	if !c.FollowSubDomains {
 		return strings.EqualFold(u.Host, c.domain)
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,7 +10,10 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/guilherme-santos/mzcrawler"
 )
@@ -57,7 +61,7 @@ func TestNormalizeURL(t *testing.T) {
 		{URL: "http://monzo.com/blog", Normalized: "http://monzo.com/blog"},
 	}
 
-	c, err := NewWebCrawler("https://monzo.com/path?query=param#fragment")
+	c, err := NewWebCrawler("https://monzo.com/path?query=param#fragment", 5)
 	noError(t, err)
 
 	for _, tc := range testcases {
@@ -81,7 +85,7 @@ func TestShouldFollow(t *testing.T) {
 		{URL: "https://blog.monzo.com/article", FollowSubDomains: true, ShouldFollow: true},
 	}
 
-	c, err := NewWebCrawler("https://monzo.com")
+	c, err := NewWebCrawler("https://monzo.com", 5)
 	noError(t, err)
 
 	for _, tc := range testcases {
@@ -93,19 +97,44 @@ func TestShouldFollow(t *testing.T) {
 	}
 }
 
+func TestContentTypeAllowed(t *testing.T) {
+	testcases := []struct {
+		ContentType         string
+		AllowedContentTypes []string
+		Allowed             bool
+	}{
+		{ContentType: "text/html", Allowed: true},
+		{ContentType: "text/html; charset=utf-8", Allowed: true},
+		{ContentType: "application/pdf", Allowed: false},
+		{ContentType: "", Allowed: false},
+		{ContentType: "application/json", AllowedContentTypes: []string{"text/html", "application/json"}, Allowed: true},
+	}
+
+	c, err := NewWebCrawler("https://monzo.com", 5)
+	noError(t, err)
+
+	for _, tc := range testcases {
+		c.AllowedContentTypes = tc.AllowedContentTypes
+
+		if !assert(t, tc.Allowed, c.contentTypeAllowed(tc.ContentType)) {
+			t.Logf("Content-Type %q", tc.ContentType)
+		}
+	}
+}
+
 func TestCrawlURL_ExtractAllHref(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		f, err := os.Open(path.Join("testdata", "github.html"))
 		noError(t, err)
+		w.Header().Set("Content-Type", "text/html")
 		io.Copy(w, f)
 	}))
 	defer ts.Close()
 
-	c, err := NewWebCrawler(ts.URL)
+	c, err := NewWebCrawler(ts.URL, 5)
 	noError(t, err)
 
-	u, _ := url.Parse(ts.URL)
-	urlCh, err := c.crawlURL(u)
+	urlCh, err := c.crawlURL(context.Background(), ts.URL)
 	noError(t, err)
 
 	var total int
@@ -119,18 +148,21 @@ func TestCrawler(t *testing.T) {
 	var ts *httptest.Server
 	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		switch req.RequestURI {
-		case "/", "/about", "contact":
+		case "/", "/about", "/contact":
+			w.Header().Set("Content-Type", "text/html")
 			fmt.Fprintf(w, `<a href="/"><a href="/about"><a href="%s/contact"><a href="https://fb.com/company">`, ts.URL)
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
 		default:
 			t.Errorf("%s is not an expected", req.RequestURI)
 		}
 	}))
 	defer ts.Close()
 
-	c, err := NewWebCrawler(ts.URL)
+	c, err := NewWebCrawler(ts.URL, 5)
 	noError(t, err)
 
-	sitemap, err := c.Crawl()
+	sitemap, err := c.Crawl(context.Background())
 	noError(t, err)
 
 	testCrawlerSitemap(t, sitemap, ts.URL, "")
@@ -138,10 +170,233 @@ func TestCrawler(t *testing.T) {
 	testCrawlerSitemap(t, sitemap, ts.URL, "/contact")
 }
 
+func TestCrawler_MaxPages(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.RequestURI {
+		case "/", "/about", "/contact":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<a href="/"><a href="/about"><a href="%s/contact">`, ts.URL)
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			t.Errorf("%s is not an expected", req.RequestURI)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewWebCrawler(ts.URL, 5)
+	noError(t, err)
+	c.MaxPages = 2
+
+	sitemap, err := c.Crawl(context.Background())
+	noError(t, err)
+
+	assert(t, 2, len(sitemap))
+}
+
+func TestCrawler_MaxPages_NoGoroutineLeak(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="%s/p1"><a href="%s/p2"><a href="%s/p3"><a href="%s/p4"><a href="%s/p5"><a href="%s/p6">`,
+			ts.URL, ts.URL, ts.URL, ts.URL, ts.URL, ts.URL)
+	}))
+	defer ts.Close()
+
+	before := runtime.NumGoroutine()
+
+	// Every page links to 6 others, so MaxPages=2 cancels the crawl
+	// while several discovered links are still sitting unprocessed in
+	// feedQueue/c.queue. Repeating this should never leave a goroutine
+	// behind parked in pending.Wait().
+	for i := 0; i < 20; i++ {
+		c, err := NewWebCrawler(ts.URL, 5)
+		noError(t, err)
+		c.MaxPages = 2
+
+		_, err = c.Crawl(context.Background())
+		noError(t, err)
+	}
+
+	var after int
+	for i := 0; i < 100; i++ {
+		after = runtime.NumGoroutine()
+		if after <= before+5 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("goroutine count grew from %d to %d after 20 cancelled crawls, looks like a leak", before, after)
+}
+
+func TestCrawler_StopOnError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.RequestURI {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<a href="/about">`)
+		case "/about":
+			// Hijack and close without writing a response, forcing
+			// the crawler's fetch of this URL to fail.
+			hj := w.(http.Hijacker)
+			conn, _, _ := hj.Hijack()
+			conn.Close()
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewWebCrawler(ts.URL, 5)
+	noError(t, err)
+	c.StopOnError = true
+
+	_, err = c.Crawl(context.Background())
+	if err == nil {
+		t.Errorf("expected an error but got none")
+	}
+}
+
+func TestCrawler_RobotsDisallow(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.RequestURI {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<a href="/private"><a href="/public">`)
+		case "/private":
+			t.Errorf("/private shouldn't have been fetched, it's disallowed by robots.txt")
+		case "/public":
+			w.Header().Set("Content-Type", "text/html")
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		default:
+			t.Errorf("%s is not expected", req.RequestURI)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewWebCrawler(ts.URL, 5)
+	noError(t, err)
+
+	sitemap, err := c.Crawl(context.Background())
+	noError(t, err)
+
+	if _, ok := sitemap[ts.URL+"/private"]; ok {
+		t.Errorf("/private shouldn't have been crawled, it's disallowed by robots.txt")
+	}
+	if _, ok := sitemap[ts.URL+"/public"]; !ok {
+		t.Errorf("/public should have been crawled")
+	}
+}
+
+func TestCrawler_Assets(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `
+			<link href="/style.css" rel="stylesheet">
+			<img src="/logo.png">
+			<script src="/app.js"></script>
+			<div style="background: url('/bg.png')"></div>
+			<style>.hero { background: url("/hero.png"); } .banner { background: url("/banner.png"); }</style>
+		`)
+	}))
+	defer ts.Close()
+
+	c, err := NewWebCrawler(ts.URL, 5)
+	noError(t, err)
+
+	sitemap, err := c.Crawl(context.Background())
+	noError(t, err)
+
+	page, ok := sitemap[ts.URL]
+	assert(t, true, ok)
+	assert(t, 0, len(page.Links))
+
+	expectedAssets := map[string]struct{}{
+		ts.URL + "/style.css":  struct{}{},
+		ts.URL + "/logo.png":   struct{}{},
+		ts.URL + "/app.js":     struct{}{},
+		ts.URL + "/bg.png":     struct{}{},
+		ts.URL + "/hero.png":   struct{}{},
+		ts.URL + "/banner.png": struct{}{},
+	}
+	for _, v := range page.Assets {
+		if _, ok := expectedAssets[v]; !ok {
+			t.Errorf("%s is not expected to be found", v)
+		}
+		delete(expectedAssets, v)
+	}
+	assert(t, 0, len(expectedAssets))
+}
+
+func TestCrawler_ContentTypeGating(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.RequestURI {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<a href="/doc.pdf">`)
+		case "/doc.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprintf(w, `%%PDF-1.4 <a href="/should-not-be-followed">`)
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			t.Errorf("%s is not expected", req.RequestURI)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewWebCrawler(ts.URL, 5)
+	noError(t, err)
+
+	sitemap, err := c.Crawl(context.Background())
+	noError(t, err)
+
+	page, ok := sitemap[ts.URL+"/doc.pdf"]
+	assert(t, true, ok)
+	assert(t, 0, len(page.Links))
+	assert(t, 0, len(page.Assets))
+
+	if _, ok := sitemap[ts.URL+"/should-not-be-followed"]; ok {
+		t.Errorf("/should-not-be-followed shouldn't have been crawled, it's only referenced from a non-HTML response")
+	}
+}
+
+func TestCrawler_MaxResponseBytes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.RequestURI {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, strings.Repeat(" ", 20))
+			fmt.Fprintf(w, `<a href="/truncated">`)
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewWebCrawler(ts.URL, 5)
+	noError(t, err)
+	c.MaxResponseBytes = 10
+
+	sitemap, err := c.Crawl(context.Background())
+	noError(t, err)
+
+	page := sitemap[ts.URL]
+	assert(t, 0, len(page.Links))
+}
+
 func testCrawlerSitemap(t *testing.T, sitemap mzcrawler.Sitemap, baseurl, path string) {
-	urls, ok := sitemap[baseurl+path]
+	page, ok := sitemap[baseurl+path]
 	assert(t, true, ok)
-	assert(t, 4, len(urls))
+	assert(t, 4, len(page.Links))
+	assert(t, 0, len(page.Assets))
 
 	expectedUrls := map[string]struct{}{
 		baseurl:                  struct{}{},
@@ -149,7 +404,7 @@ func testCrawlerSitemap(t *testing.T, sitemap mzcrawler.Sitemap, baseurl, path s
 		baseurl + "/contact":     struct{}{},
 		"https://fb.com/company": struct{}{},
 	}
-	for _, v := range urls {
+	for _, v := range page.Links {
 		if _, ok := expectedUrls[v]; !ok {
 			t.Errorf("%s is not expected to be found", v)
 		}
@@ -1,10 +1,21 @@
 package mzcrawler
 
+import "context"
+
 // WebCrawler defines an interface to crawl an website.
 type WebCrawler interface {
-	// Crawl returns a map with all URLs visited and the list of
-	// urls found in each one.
-	Crawl() (Sitemap, error)
+	// Crawl returns a map with all URLs visited and the Page found in
+	// each one. It stops and returns ctx.Err() as soon as ctx is
+	// cancelled.
+	Crawl(ctx context.Context) (Sitemap, error)
+}
+
+// Page holds what was found while crawling a single URL: the links
+// that can be followed to reach other pages, and the assets (images,
+// scripts, stylesheets, ...) it references but that aren't crawled.
+type Page struct {
+	Links  []string
+	Assets []string
 }
 
-type Sitemap map[string][]string
+type Sitemap map[string]Page
@@ -0,0 +1,35 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/guilherme-santos/mzcrawler"
+)
+
+// dotWriter writes sitemap as a GraphViz DOT directed graph, with an
+// edge from a page to every link it contains.
+type dotWriter struct{}
+
+// NewDOTWriter creates a Writer that encodes the sitemap as a GraphViz
+// DOT directed graph.
+func NewDOTWriter() Writer {
+	return dotWriter{}
+}
+
+func (dotWriter) Write(sitemap mzcrawler.Sitemap, w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph sitemap {"); err != nil {
+		return err
+	}
+
+	for urlstr, page := range sitemap {
+		for _, link := range page.Links {
+			if _, err := fmt.Fprintf(w, "\t%q -> %q;\n", urlstr, link); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
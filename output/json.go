@@ -0,0 +1,23 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/guilherme-santos/mzcrawler"
+)
+
+// jsonWriter writes the whole sitemap as a single indented JSON object.
+type jsonWriter struct{}
+
+// NewJSONWriter creates a Writer that encodes the sitemap as a single
+// JSON object.
+func NewJSONWriter() Writer {
+	return jsonWriter{}
+}
+
+func (jsonWriter) Write(sitemap mzcrawler.Sitemap, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "   ")
+	return enc.Encode(sitemap)
+}
@@ -0,0 +1,39 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/guilherme-santos/mzcrawler"
+)
+
+// PageRecord is the shape of a single line written by NDJSONWriter, and
+// is also what callers streaming pages as they're crawled (via
+// http.WebCrawler's OnPageCrawled hook) encode. That hook only passes a
+// page's links, so streamed records never have an "assets" field.
+type PageRecord struct {
+	URL    string   `json:"url"`
+	Links  []string `json:"links"`
+	Assets []string `json:"assets,omitempty"`
+}
+
+// ndjsonWriter writes sitemap as newline-delimited JSON, one PageRecord
+// per line.
+type ndjsonWriter struct{}
+
+// NewNDJSONWriter creates a Writer that encodes the sitemap as
+// newline-delimited JSON, one PageRecord per line.
+func NewNDJSONWriter() Writer {
+	return ndjsonWriter{}
+}
+
+func (ndjsonWriter) Write(sitemap mzcrawler.Sitemap, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for urlstr, page := range sitemap {
+		rec := PageRecord{URL: urlstr, Links: page.Links, Assets: page.Assets}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+// Package output provides Writer implementations that serialize a
+// mzcrawler.Sitemap into different formats.
+package output
+
+import (
+	"io"
+
+	"github.com/guilherme-santos/mzcrawler"
+)
+
+// Writer writes sitemap in some specific format to w.
+type Writer interface {
+	Write(sitemap mzcrawler.Sitemap, w io.Writer) error
+}
@@ -0,0 +1,80 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/guilherme-santos/mzcrawler"
+)
+
+func TestXMLWriter_Write(t *testing.T) {
+	sitemap := mzcrawler.Sitemap{
+		"https://monzo.com": mzcrawler.Page{Links: []string{"https://monzo.com/blog"}},
+	}
+
+	var buf bytes.Buffer
+	if err := NewXMLWriter().Write(sitemap, &buf); err != nil {
+		t.Fatalf("No error expected but got: %s", err)
+	}
+
+	got := buf.String()
+	if !strings.Contains(got, "<urlset") {
+		t.Errorf("expected output to contain <urlset>, got %s", got)
+	}
+	if !strings.Contains(got, "<loc>https://monzo.com</loc>") {
+		t.Errorf("expected output to contain the page URL, got %s", got)
+	}
+}
+
+func TestDOTWriter_Write(t *testing.T) {
+	sitemap := mzcrawler.Sitemap{
+		"https://monzo.com": mzcrawler.Page{Links: []string{"https://monzo.com/blog"}},
+	}
+
+	var buf bytes.Buffer
+	if err := NewDOTWriter().Write(sitemap, &buf); err != nil {
+		t.Fatalf("No error expected but got: %s", err)
+	}
+
+	got := buf.String()
+	if !strings.HasPrefix(got, "digraph sitemap {") {
+		t.Errorf("expected output to start with digraph sitemap {, got %s", got)
+	}
+	if !strings.Contains(got, `"https://monzo.com" -> "https://monzo.com/blog";`) {
+		t.Errorf("expected output to contain the edge, got %s", got)
+	}
+}
+
+func TestJSONWriter_Write(t *testing.T) {
+	sitemap := mzcrawler.Sitemap{
+		"https://monzo.com": mzcrawler.Page{Links: []string{"https://monzo.com/blog"}},
+	}
+
+	var buf bytes.Buffer
+	if err := NewJSONWriter().Write(sitemap, &buf); err != nil {
+		t.Fatalf("No error expected but got: %s", err)
+	}
+
+	got := buf.String()
+	if !strings.Contains(got, `"https://monzo.com"`) {
+		t.Errorf("expected output to contain the page URL, got %s", got)
+	}
+}
+
+func TestNDJSONWriter_Write(t *testing.T) {
+	sitemap := mzcrawler.Sitemap{
+		"https://monzo.com":      mzcrawler.Page{Links: []string{"https://monzo.com/blog"}},
+		"https://monzo.com/blog": mzcrawler.Page{},
+	}
+
+	var buf bytes.Buffer
+	if err := NewNDJSONWriter().Write(sitemap, &buf); err != nil {
+		t.Fatalf("No error expected but got: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(sitemap) {
+		t.Errorf("expected %d lines but got %d", len(sitemap), len(lines))
+	}
+}
@@ -0,0 +1,47 @@
+package output
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/guilherme-santos/mzcrawler"
+)
+
+// xmlWriter writes sitemap following the sitemaps.org protocol, see
+// https://www.sitemaps.org/protocol.html.
+type xmlWriter struct{}
+
+// NewXMLWriter creates a Writer that encodes the sitemap as a
+// sitemaps.org XML urlset.
+func NewXMLWriter() Writer {
+	return xmlWriter{}
+}
+
+type urlset struct {
+	XMLName xml.Name `xml:"urlset"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	URLs    []xmlURL `xml:"url"`
+}
+
+type xmlURL struct {
+	Loc string `xml:"loc"`
+}
+
+func (xmlWriter) Write(sitemap mzcrawler.Sitemap, w io.Writer) error {
+	set := urlset{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for urlstr := range sitemap {
+		set.URLs = append(set.URLs, xmlURL{Loc: urlstr})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}